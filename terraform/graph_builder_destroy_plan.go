@@ -0,0 +1,104 @@
+package terraform
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/states"
+)
+
+// DestroyPlanGraphBuilder is a GraphBuilder used to plan a pure-destroy
+// operation: one that tears down the objects described by Config without
+// otherwise updating them.
+//
+// It swaps in DestroyModuleExpansionTransformer where an ordinary plan
+// would use ModuleExpansionTransformer, so that module teardown is ordered
+// leaf-first: a child module's resources are destroyed, and its close node
+// reached, before the module call that produced it is itself considered
+// finished. This is what lets `terraform destroy -target=module.foo`
+// correctly walk into, and fully tear down, nested submodules.
+type DestroyPlanGraphBuilder struct {
+	// Config is the configuration tree describing the module structure to
+	// be torn down.
+	Config *configs.Config
+
+	// State is the prior run state, which is what actually tells the
+	// walk which resource instances exist and need to be destroyed; the
+	// configuration alone only describes module structure.
+	State *states.State
+
+	// Targets, if non-empty, restricts the destroy to the given resources
+	// and modules (e.g. `-target=module.foo`) and their dependencies. A
+	// nil or empty Targets destroys everything, same as TargetsTransformer
+	// elsewhere in the codebase.
+	Targets []addrs.Targetable
+
+	// Concrete, if set, is passed through to DestroyModuleExpansionTransformer
+	// so the destroy walk can attach its own module node types.
+	Concrete ConcreteModuleNodeFunc
+}
+
+// Build implements GraphBuilder.
+func (b *DestroyPlanGraphBuilder) Build(path addrs.ModuleInstance) (*Graph, error) {
+	g := &Graph{Path: path}
+
+	for _, tf := range b.Steps() {
+		if err := tf.Transform(g); err != nil {
+			return nil, fmt.Errorf("DestroyPlanGraphBuilder: %s", err)
+		}
+	}
+
+	return g, nil
+}
+
+// Steps returns the ordered set of transforms that Build applies.
+func (b *DestroyPlanGraphBuilder) Steps() []GraphTransformer {
+	return []GraphTransformer{
+		&ConfigTransformer{Config: b.Config},
+
+		// DestroyModuleExpansionTransformer takes the place of
+		// ModuleExpansionTransformer here so that module teardown is
+		// ordered leaf-first instead of root-first.
+		&DestroyModuleExpansionTransformer{
+			Config:   b.Config,
+			Concrete: b.Concrete,
+		},
+
+		// AttachStateTransformer gives each resource node the prior run
+		// state describing the instances it actually has, since that is
+		// what a destroy walk acts on; the configuration alone only
+		// describes module structure.
+		&AttachStateTransformer{State: b.State},
+
+		// ReferenceTransformer wires the dependency edges implied by
+		// config references, such as one resource's configuration
+		// referring to another's attributes, so the walk respects those
+		// relationships rather than only module boundaries.
+		&ReferenceTransformer{},
+
+		// TargetsTransformer prunes the graph down to the resources and
+		// modules named in Targets and whatever they depend on, which is
+		// what makes `terraform destroy -target=module.foo` walk only
+		// that submodule's leaf-first teardown instead of the whole
+		// configuration. It is a no-op when Targets is empty.
+		&TargetsTransformer{Targets: b.Targets},
+
+		// ProviderTransformer connects each resource to the provider
+		// configuration node it was declared against, and
+		// PruneProviderTransformer then drops any provider node that
+		// nothing ended up depending on.
+		&ProviderTransformer{},
+		&PruneProviderTransformer{},
+
+		// RootTransformer adds the single root node that the rest of the
+		// plan machinery expects the graph to have. Build itself does not
+		// validate the graph or check it for cycles.
+		&RootTransformer{},
+
+		// TransitiveReductionTransformer trims the redundant edges left
+		// behind by the transformers above so the graph is as small as
+		// it can be before it is walked.
+		&TransitiveReductionTransformer{},
+	}
+}
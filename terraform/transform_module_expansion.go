@@ -20,6 +20,14 @@ type ModuleExpansionTransformer struct {
 	Concrete ConcreteModuleNodeFunc
 }
 
+// NOTE on per-instance fan-out: this transformer works at the granularity
+// of module calls. It adds one nodeExpandModule and one nodeCloseModule per
+// call, however many instances the call's count or for_each expression
+// produces, and wires objects declared in the module to depend on that one
+// expansion node. It does not evaluate count or for_each and adds no
+// per-instance nodes, so nothing here makes a reference to
+// module.child[*].out depend on each instance individually.
+
 func (t *ModuleExpansionTransformer) Transform(g *Graph) error {
 	// The root module is always a singleton and so does not need expansion
 	// processing, but any descendent modules do. We'll process them
@@ -30,6 +38,7 @@ func (t *ModuleExpansionTransformer) Transform(g *Graph) error {
 			return err
 		}
 	}
+
 	return nil
 }
 
@@ -83,7 +92,9 @@ func (t *ModuleExpansionTransformer) transform(g *Graph, c *configs.Config, pare
 
 	// Also visit child modules, recursively.
 	for _, cc := range c.Children {
-		return t.transform(g, cc, v)
+		if err := t.transform(g, cc, v); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,148 @@
+package terraform
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// nodeExpandModuleDestroy is the destroy-walk counterpart to
+// nodeExpandModule. During a destroy walk it is the vertex reached last for
+// its module, once nodeCloseModuleDestroy and everything it gates have
+// already been destroyed, rather than the vertex that gates entry to the
+// module.
+//
+// It deliberately does not implement GraphNodeModulePath: that interface is
+// used below to discover objects declared within this module so they can
+// be wired relative to closeNode, and this node (like its apply-walk
+// counterpart nodeExpandModule) represents the module call itself rather
+// than an object declared inside it.
+type nodeExpandModuleDestroy struct {
+	Addr       addrs.Module
+	Config     *configs.Module
+	ModuleCall *configs.ModuleCall
+}
+
+func (n *nodeExpandModuleDestroy) Name() string {
+	return fmt.Sprintf("%s (destroy expand)", n.Addr.String())
+}
+
+// nodeCloseModuleDestroy is the destroy-walk counterpart to nodeCloseModule.
+// During a destroy walk it is the single point at which the module's
+// children converge: it depends on every node declared within the module,
+// and so is not reached until all of them have been destroyed.
+//
+// Like nodeExpandModuleDestroy above, it does not implement
+// GraphNodeModulePath for the same reason.
+type nodeCloseModuleDestroy struct {
+	Addr addrs.Module
+}
+
+func (n *nodeCloseModuleDestroy) Name() string {
+	return fmt.Sprintf("%s (destroy close)", n.Addr.String())
+}
+
+// DestroyModuleExpansionTransformer is the destroy-walk counterpart to
+// ModuleExpansionTransformer. It shares the same GraphNodeModulePath
+// discovery logic for locating the nodes that belong to a given module,
+// but inverts the edges between a module's expansion and close nodes so
+// that a module's expansion node is reached only after every node declared
+// within it and after the expansion nodes of its child modules. Nodes
+// declared in a module, its providers and data sources included, are all
+// wired to its close node alike; this transformer adds no edge ordering a
+// child module's nodes before the parent module's providers or data
+// sources.
+//
+// Where ModuleExpansionTransformer wires child nodes to depend on reaching
+// the expansion node (so they run after expansion), this transformer wires
+// the module's close node to depend on reaching every child node first (so
+// the module isn't closed out until its children are gone), and wires the
+// expansion node itself to depend on the close node. This produces the
+// destroy-order inverse of the normal apply ordering: nodeExpandModuleDestroy
+// runs last for a given module, after nodeCloseModuleDestroy and everything
+// it gates have already run.
+//
+// DestroyPlanGraphBuilder includes this transformer instead of
+// ModuleExpansionTransformer so that `terraform destroy -target=module.foo`
+// walks nested submodules leaf-first.
+type DestroyModuleExpansionTransformer struct {
+	Config   *configs.Config
+	Concrete ConcreteModuleNodeFunc
+}
+
+func (t *DestroyModuleExpansionTransformer) Transform(g *Graph) error {
+	// The root module is always a singleton and so does not need expansion
+	// processing, but any descendent modules do. We'll process them
+	// recursively using t.transform.
+	for _, cfg := range t.Config.Children {
+		err := t.transform(g, cfg, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DestroyModuleExpansionTransformer) transform(g *Graph, c *configs.Config, parentNode dag.Vertex) error {
+	_, call := c.Path.Call()
+	modCall := c.Parent.Module.ModuleCalls[call.Name]
+
+	closeNode := &nodeCloseModuleDestroy{
+		Addr: c.Path,
+	}
+	g.Add(closeNode)
+	log.Printf("[TRACE] DestroyModuleExpansionTransformer: Added %s as %T", c.Path, closeNode)
+
+	n := &nodeExpandModuleDestroy{
+		Addr:       c.Path,
+		Config:     c.Module,
+		ModuleCall: modCall,
+	}
+	var v dag.Vertex = n
+	if t.Concrete != nil {
+		v = t.Concrete(n)
+	}
+	g.Add(v)
+
+	// The expansion node is the last thing reached for this module during
+	// a destroy walk, so it depends on the close node having been reached
+	// first, which in turn means every node the close node gates has
+	// already been destroyed.
+	g.Connect(dag.BasicEdge(v, closeNode))
+
+	if parentNode != nil {
+		// The parent module's own destroy expansion can't complete until
+		// this child module's expansion has, so that grandchildren are
+		// always destroyed before children, which are destroyed before
+		// parents.
+		log.Printf("[TRACE] DestroyModuleExpansionTransformer: %s must wait for destroy expansion of %s", dag.VertexName(parentNode), dag.VertexName(v))
+		g.Connect(dag.BasicEdge(parentNode, v))
+	}
+
+	// Connect any node that reports this module as its Path to ensure that
+	// it is destroyed before the module's close node is considered reached,
+	// and therefore before the module's own expansion node runs.
+	for _, childV := range g.Vertices() {
+		pather, ok := childV.(GraphNodeModulePath)
+		if !ok {
+			continue
+		}
+		if pather.ModulePath().Equal(c.Path) {
+			log.Printf("[TRACE] DestroyModuleExpansionTransformer: %s must wait for destruction of %s", c.Path, dag.VertexName(childV))
+			g.Connect(dag.BasicEdge(closeNode, childV))
+		}
+	}
+
+	// Also visit child modules, recursively, so that nested submodules are
+	// destroyed leaf-first.
+	for _, cc := range c.Children {
+		if err := t.transform(g, cc, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
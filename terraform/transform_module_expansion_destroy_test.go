@@ -0,0 +1,81 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// TestDestroyPlanGraphBuilder_leafFirst proves that, for a module nested
+// two levels deep, DestroyPlanGraphBuilder wires the child module's destroy
+// expansion ahead of its parent's, so a destroy walk reaches the child
+// module's objects - and closes the child module out - before the parent
+// module that declared it is itself considered finished.
+func TestDestroyPlanGraphBuilder_leafFirst(t *testing.T) {
+	mod := testModuleInline(t, map[string]string{
+		"main.tf": `
+module "parent" {
+  source = "./parent"
+}
+`,
+		"parent/main.tf": `
+resource "aws_instance" "parent" {
+}
+
+module "child" {
+  source = "../child"
+}
+`,
+		"child/main.tf": `
+resource "aws_instance" "child" {
+}
+`,
+	})
+
+	b := &DestroyPlanGraphBuilder{Config: mod}
+	g, err := b.Build(addrs.RootModuleInstance)
+	if err != nil {
+		t.Fatalf("DestroyPlanGraphBuilder.Build failed: %s", err)
+	}
+
+	parentAddr := addrs.RootModule.Child("parent")
+	childAddr := parentAddr.Child("child")
+
+	var expandParent, expandChild, closeChild dag.Vertex
+	for _, v := range g.Vertices() {
+		switch n := v.(type) {
+		case *nodeExpandModuleDestroy:
+			switch {
+			case n.Addr.Equal(parentAddr):
+				expandParent = v
+			case n.Addr.Equal(childAddr):
+				expandChild = v
+			}
+		case *nodeCloseModuleDestroy:
+			if n.Addr.Equal(childAddr) {
+				closeChild = v
+			}
+		}
+	}
+
+	if expandParent == nil || expandChild == nil {
+		t.Fatalf("missing destroy expansion node for parent and/or child module")
+	}
+	if closeChild == nil {
+		t.Fatalf("missing destroy close node for child module")
+	}
+
+	// The parent module's destroy expansion must wait on the child
+	// module's, proving leaf-first ordering.
+	if !g.HasEdge(dag.BasicEdge(expandParent, expandChild)) {
+		t.Fatalf("parent module's destroy expansion is not wired to wait for the child module's")
+	}
+
+	// The child module's own destroy expansion must wait on its close node
+	// having been reached, i.e. on aws_instance.child already having been
+	// destroyed.
+	if !g.HasEdge(dag.BasicEdge(expandChild, closeChild)) {
+		t.Fatalf("child module's destroy expansion is not wired to wait for its own close node")
+	}
+}
@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// TestModuleExpansionTransformer_siblingModules ensures that the transformer
+// visits every child module at a given level, not just the first one. This
+// guards against a regression where the recursive walk in transform
+// returned after handling only the first entry in c.Children, silently
+// skipping any sibling modules declared alongside it.
+//
+// The siblings are nested under a shared parent module rather than
+// declared directly in the root, because the root's own children are
+// visited by Transform's top-level loop over t.Config.Children, which
+// never had the bug. Only transform's recursion into c.Children, which
+// fires for a non-root parent with multiple child modules, is what this
+// test needs to exercise.
+func TestModuleExpansionTransformer_siblingModules(t *testing.T) {
+	mod := testModuleInline(t, map[string]string{
+		"main.tf": `
+module "parent" {
+  source = "./parent"
+}
+`,
+		"parent/main.tf": `
+module "a" {
+  source = "../child"
+}
+
+module "b" {
+  source = "../child"
+}
+`,
+		"child/main.tf": `
+resource "aws_instance" "foo" {
+}
+`,
+	})
+
+	g := &Graph{Path: addrs.RootModuleInstance}
+
+	ct := &ConfigTransformer{Config: mod}
+	if err := ct.Transform(g); err != nil {
+		t.Fatalf("ConfigTransformer.Transform failed: %s", err)
+	}
+
+	transform := &ModuleExpansionTransformer{Config: mod}
+	if err := transform.Transform(g); err != nil {
+		t.Fatalf("ModuleExpansionTransformer.Transform failed: %s", err)
+	}
+
+	parent := addrs.RootModule.Child("parent")
+	moduleA := parent.Child("a")
+	moduleB := parent.Child("b")
+
+	var expandA, expandB dag.Vertex
+	var closeA, closeB dag.Vertex
+	for _, v := range g.Vertices() {
+		switch n := v.(type) {
+		case *nodeExpandModule:
+			switch {
+			case n.Addr.Equal(moduleA):
+				expandA = v
+			case n.Addr.Equal(moduleB):
+				expandB = v
+			}
+		case *nodeCloseModule:
+			switch {
+			case n.Addr.Equal(moduleA):
+				closeA = v
+			case n.Addr.Equal(moduleB):
+				closeB = v
+			}
+		}
+	}
+
+	if expandA == nil || expandB == nil {
+		t.Fatalf("missing expansion node for one or both sibling modules (found %d vertices total)", len(g.Vertices()))
+	}
+	if closeA == nil || closeB == nil {
+		t.Fatalf("missing close node for one or both sibling modules")
+	}
+
+	if !g.HasEdge(dag.BasicEdge(closeA, expandA)) {
+		t.Fatalf("close node for module.a is not connected to its own expansion node")
+	}
+	if !g.HasEdge(dag.BasicEdge(closeB, expandB)) {
+		t.Fatalf("close node for module.b is not connected to its own expansion node")
+	}
+}
+
+// TestModuleExpansionTransformer_closeWaitsOnContents ensures that a
+// module's close node depends on every object declared within it - even
+// when the module call itself uses count - since that close node is what a
+// consumer of module.child[*].out ultimately depends on.
+func TestModuleExpansionTransformer_closeWaitsOnContents(t *testing.T) {
+	mod := testModuleInline(t, map[string]string{
+		"main.tf": `
+module "child" {
+  source = "./child"
+  count  = 3
+}
+`,
+		"child/main.tf": `
+resource "aws_instance" "foo" {
+}
+`,
+	})
+
+	g := &Graph{Path: addrs.RootModuleInstance}
+
+	ct := &ConfigTransformer{Config: mod}
+	if err := ct.Transform(g); err != nil {
+		t.Fatalf("ConfigTransformer.Transform failed: %s", err)
+	}
+
+	transform := &ModuleExpansionTransformer{Config: mod}
+	if err := transform.Transform(g); err != nil {
+		t.Fatalf("ModuleExpansionTransformer.Transform failed: %s", err)
+	}
+
+	moduleChild := addrs.RootModule.Child("child")
+
+	var closeChild, resourceFoo dag.Vertex
+	for _, v := range g.Vertices() {
+		switch n := v.(type) {
+		case *nodeCloseModule:
+			if n.Addr.Equal(moduleChild) {
+				closeChild = v
+			}
+		case GraphNodeModulePath:
+			if n.ModulePath().Equal(moduleChild) {
+				resourceFoo = v
+			}
+		}
+	}
+
+	if closeChild == nil {
+		t.Fatalf("missing close node for module.child")
+	}
+	if resourceFoo == nil {
+		t.Fatalf("missing node for aws_instance.foo in module.child")
+	}
+	if !g.HasEdge(dag.BasicEdge(closeChild, resourceFoo)) {
+		t.Fatalf("close node for module.child is not connected to aws_instance.foo")
+	}
+}